@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sqleo/parse-video/queue"
+)
+
+// batchRequestItem 是 POST /batch 请求体里的一项，与 queue.Item 字段一致，
+// 单独定义是为了让 JSON 绑定与内部类型解耦，便于以后独立演进请求格式。
+type batchRequestItem struct {
+	ShareURL string `json:"share_url,omitempty"`
+	Source   string `json:"source,omitempty"`
+	VideoID  string `json:"video_id,omitempty"`
+}
+
+// handleBatchSubmit 实现 POST /batch：接收一批分享链接 / (source, video_id)，异步排队解析。
+func handleBatchSubmit(c *gin.Context) {
+	var items []batchRequestItem
+	if err := c.ShouldBindJSON(&items); err != nil {
+		c.JSON(http.StatusBadRequest, HttpResponse{Code: 400, Msg: err.Error()})
+		return
+	}
+	if len(items) == 0 {
+		c.JSON(http.StatusBadRequest, HttpResponse{Code: 400, Msg: "empty batch"})
+		return
+	}
+
+	qItems := make([]queue.Item, 0, len(items))
+	for _, it := range items {
+		qItems = append(qItems, queue.Item{ShareURL: it.ShareURL, Source: it.Source, VideoID: it.VideoID})
+	}
+
+	jobID, err := queue.Submit(c.Request.Context(), qItems)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, HttpResponse{Code: 500, Msg: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, HttpResponse{Code: 200, Msg: "ok", Data: gin.H{"job_id": jobID}})
+}
+
+// handleBatchProgress 实现 GET /batch/:job_id：返回批次进度，完成后附带完整结果列表。
+func handleBatchProgress(c *gin.Context) {
+	jobID := c.Param("job_id")
+	progress, err := queue.GetProgress(c.Request.Context(), jobID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, HttpResponse{Code: 500, Msg: err.Error()})
+		return
+	}
+	if progress == nil {
+		c.JSON(http.StatusNotFound, HttpResponse{Code: 404, Msg: "job not found"})
+		return
+	}
+	c.JSON(http.StatusOK, HttpResponse{Code: 200, Msg: "ok", Data: progress})
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sqleo/parse-video/parser"
+	"github.com/sqleo/parse-video/storage"
+)
+
+// handleBundle 实现 GET /bundle：把一次解析结果（视频、音乐、封面、图集）打包为一个 zip 流式返回。
+// 输入与 /video/share/url/parse、/video/id/parse 一致（url 或 source+video_id），也支持通过
+// 已有解析记录的 id 直接复用结果，避免重复解析。
+func handleBundle(c *gin.Context) {
+	var (
+		parseRes  *parser.VideoParseInfo
+		sourceURL string
+		source    string
+		err       error
+	)
+
+	switch {
+	case c.Query("id") != "":
+		id, convErr := strconv.ParseInt(c.Query("id"), 10, 64)
+		if convErr != nil {
+			c.String(http.StatusBadRequest, "invalid id")
+			return
+		}
+		rec, getErr := storage.GetByID(c.Request.Context(), id)
+		if getErr != nil {
+			c.String(http.StatusInternalServerError, getErr.Error())
+			return
+		}
+		if rec == nil || rec.Result == nil {
+			c.String(http.StatusNotFound, "record not found or has no result")
+			return
+		}
+		parseRes = rec.Result
+		sourceURL = rec.Input.ShareURL
+		source = rec.Source
+	case c.Query("url") != "":
+		sourceURL = c.Query("url")
+		parseRes, err = parser.ParseVideoShareUrlByRegexp(sourceURL)
+	default:
+		source = c.Query("source")
+		sourceURL = c.Query("video_id")
+		parseRes, err = parser.ParseVideoId(source, sourceURL)
+	}
+
+	if err != nil {
+		_ = storage.Append(c.Request.Context(), storage.Record{
+			Endpoint:  "/bundle",
+			Source:    source,
+			Input:     storage.Input{ShareURL: c.Query("url"), VideoID: c.Query("video_id")},
+			ClientIP:  c.ClientIP(),
+			UserAgent: c.GetHeader("User-Agent"),
+			Error:     err.Error(),
+		})
+		c.String(http.StatusBadGateway, err.Error())
+		return
+	}
+	if parseRes == nil {
+		c.String(http.StatusBadRequest, "missing url, source/video_id or id")
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", `attachment; filename="bundle.zip"`)
+	c.Status(http.StatusOK)
+
+	client := newAntiHotlinkClient()
+	zw := zip.NewWriter(c.Writer)
+
+	if _, err := writeZipEntry(zw, client, "video", parseRes.VideoUrl); err != nil {
+		log.Printf("bundle: write video entry failed: %v", err)
+	}
+	if _, err := writeZipEntry(zw, client, "music", parseRes.MusicUrl); err != nil {
+		log.Printf("bundle: write music entry failed: %v", err)
+	}
+	if _, err := writeZipEntry(zw, client, "cover", parseRes.CoverUrl); err != nil {
+		log.Printf("bundle: write cover entry failed: %v", err)
+	}
+	for i, img := range parseRes.Images {
+		name := fmt.Sprintf("images/%03d", i+1)
+		if _, err := writeZipEntry(zw, client, name, img); err != nil {
+			log.Printf("bundle: write image %d entry failed: %v", i+1, err)
+		}
+	}
+
+	meta := struct {
+		Source string                 `json:"source"`
+		Result *parser.VideoParseInfo `json:"result"`
+	}{Source: sourceURL, Result: parseRes}
+	if metaW, err := zw.Create("meta.json"); err == nil {
+		enc := json.NewEncoder(metaW)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(meta)
+	}
+
+	if err := zw.Close(); err != nil {
+		log.Printf("bundle: close zip writer failed: %v", err)
+	}
+
+	_ = storage.Append(c.Request.Context(), storage.Record{
+		Endpoint:  "/bundle",
+		Source:    source,
+		Input:     storage.Input{ShareURL: c.Query("url"), VideoID: c.Query("video_id")},
+		ClientIP:  c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+		Result:    parseRes,
+	})
+}
+
+// writeZipEntry 拉取单个资源并以 baseName+(按 Content-Type 推断的扩展名) 写入 zip，流式拷贝、不缓冲整包。
+// rawURL 为空时跳过（例如解析结果本就没有图集或音乐）。
+func writeZipEntry(zw *zip.Writer, client *http.Client, baseName, rawURL string) (string, error) {
+	if rawURL == "" {
+		return "", nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	applyAntiHotlinkHeaders(req, u)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return "", fmt.Errorf("fetch %s failed: status %d", rawURL, resp.StatusCode)
+	}
+
+	ext := extForContentType(resp.Header.Get("Content-Type"))
+	if ext == "" {
+		ext = ".bin"
+	}
+	name := baseName + ext
+	w, err := zw.Create(name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return "", err
+	}
+	return name, nil
+}
@@ -0,0 +1,247 @@
+// Package cookiejar 提供一个落在 storage 同一个 SQLite 文件里的按域名持久化 Cookie 存储，
+// 并实现 http.CookieJar，供各 parser 发起请求时携带登录态 Cookie（不少目标平台对未登录
+// 请求会返回降级甚至空的数据）。parser 内部用的是未显式设置 Jar 的裸 http.Client/http.Get，
+// 没有入口可以注入 Jar，所以 Init 会把 http.DefaultTransport 换成本包的 RoundTripper，在
+// Transport 这一层统一完成 Cookie 的注入与回收——只要调用方没有自带 Transport（parser 和
+// newAntiHotlinkClient 都没有），请求就会落到这里。
+package cookiejar
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sqleo/parse-video/storage"
+)
+
+// Cookie 是对外暴露的最小字段集合，用于导入/导出及 GET /cookies 的展示。
+type Cookie struct {
+	Host     string `json:"host"`
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path"`
+	Expires  int64  `json:"expires"` // unix 秒；0 表示会话 cookie，不过期
+	Secure   bool   `json:"secure"`
+	HttpOnly bool   `json:"http_only"`
+}
+
+// Store 是持久化在 SQLite 里的 cookie jar，实现 http.CookieJar。
+type Store struct{}
+
+var shared = &Store{}
+
+// Shared 返回进程内共享的 Store。
+func Shared() *Store { return shared }
+
+// Init 创建 cookies 表，并接管 http.DefaultTransport，使进程内所有未自带 Transport 的
+// http.Client（包括 parser 发起的裸请求）都经由本 Store 携带/回收 Cookie。
+func Init() error {
+	db := storage.DB()
+	if db == nil {
+		return fmt.Errorf("cookiejar: storage not initialized")
+	}
+	_, err := db.Exec(`
+CREATE TABLE IF NOT EXISTS cookies (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	host TEXT NOT NULL,
+	name TEXT NOT NULL,
+	value TEXT NOT NULL,
+	path TEXT NOT NULL DEFAULT '/',
+	expires INTEGER NOT NULL DEFAULT 0,
+	secure INTEGER NOT NULL DEFAULT 0,
+	http_only INTEGER NOT NULL DEFAULT 0,
+	UNIQUE(host, name, path)
+);
+CREATE INDEX IF NOT EXISTS idx_cookies_host ON cookies(host);
+`)
+	if err != nil {
+		return err
+	}
+	installTransport()
+	return nil
+}
+
+// installTransport 把 http.DefaultTransport 替换为带 Cookie 注入/回收的 jarTransport，
+// 幂等——重复调用（例如测试里多次 Init）不会套娃包装。
+func installTransport() {
+	if _, already := http.DefaultTransport.(*jarTransport); already {
+		return
+	}
+	http.DefaultTransport = &jarTransport{base: http.DefaultTransport, store: shared}
+}
+
+// jarTransport 是一个在请求前注入 Cookie、响应后把 Set-Cookie 写回 store 的 http.RoundTripper，
+// 用于在不修改调用方代码的前提下给裸 http.Client 挂上这个 Store。
+type jarTransport struct {
+	base  http.RoundTripper
+	store *Store
+}
+
+func (t *jarTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	for _, c := range t.store.Cookies(out.URL) {
+		out.AddCookie(c)
+	}
+	resp, err := t.base.RoundTrip(out)
+	if err == nil && resp != nil {
+		t.store.SetCookies(out.URL, resp.Cookies())
+	}
+	return resp, err
+}
+
+// SetCookies 实现 http.CookieJar：把响应里 Set-Cookie 的结果 upsert 进 SQLite。
+func (s *Store) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	db := storage.DB()
+	if db == nil {
+		return
+	}
+	host := u.Hostname()
+	for _, c := range cookies {
+		p := c.Path
+		if p == "" {
+			p = "/"
+		}
+		var expires int64
+		if !c.Expires.IsZero() {
+			expires = c.Expires.Unix()
+		} else if c.MaxAge > 0 {
+			expires = time.Now().Add(time.Duration(c.MaxAge) * time.Second).Unix()
+		}
+		_, _ = db.Exec(`
+INSERT INTO cookies(host, name, value, path, expires, secure, http_only)
+VALUES (?,?,?,?,?,?,?)
+ON CONFLICT(host, name, path) DO UPDATE SET
+	value = excluded.value,
+	expires = excluded.expires,
+	secure = excluded.secure,
+	http_only = excluded.http_only`,
+			host, c.Name, c.Value, p, expires, c.Secure, c.HttpOnly,
+		)
+	}
+}
+
+// Cookies 实现 http.CookieJar：返回所有 host 是请求 host 后缀（domain cookie 语义）、
+// path 匹配、未过期、且在 http/https 限制下可用的 cookie。
+func (s *Store) Cookies(u *url.URL) []*http.Cookie {
+	all, err := listAll()
+	if err != nil {
+		return nil
+	}
+	host := u.Hostname()
+	now := time.Now().Unix()
+
+	var out []*http.Cookie
+	for _, c := range all {
+		if !hostMatches(host, c.Host) {
+			continue
+		}
+		if c.Expires != 0 && c.Expires < now {
+			continue
+		}
+		if c.Secure && u.Scheme != "https" {
+			continue
+		}
+		if c.Path != "" && c.Path != "/" && !strings.HasPrefix(u.Path, c.Path) {
+			continue
+		}
+		out = append(out, &http.Cookie{Name: c.Name, Value: c.Value})
+	}
+	return out
+}
+
+// hostMatches 实现简化的 domain-cookie 匹配：请求 host 等于存储的 host，或以
+// ".存储host" 结尾（子域名也携带父域 cookie）。
+func hostMatches(reqHost, cookieHost string) bool {
+	reqHost = strings.ToLower(reqHost)
+	cookieHost = strings.ToLower(strings.TrimPrefix(cookieHost, "."))
+	return reqHost == cookieHost || strings.HasSuffix(reqHost, "."+cookieHost)
+}
+
+func listAll() ([]Cookie, error) {
+	db := storage.DB()
+	if db == nil {
+		return nil, fmt.Errorf("cookiejar: storage not initialized")
+	}
+	rows, err := db.Query(`SELECT host, name, value, path, expires, secure, http_only FROM cookies`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Cookie
+	for rows.Next() {
+		var c Cookie
+		if err := rows.Scan(&c.Host, &c.Name, &c.Value, &c.Path, &c.Expires, &c.Secure, &c.HttpOnly); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// List 返回某个 host 的全部 cookie，供 GET /cookies?host= 使用。
+func List(host string) ([]Cookie, error) {
+	db := storage.DB()
+	if db == nil {
+		return nil, fmt.Errorf("cookiejar: storage not initialized")
+	}
+	rows, err := db.Query(`SELECT host, name, value, path, expires, secure, http_only FROM cookies WHERE host = ?`, host)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Cookie
+	for rows.Next() {
+		var c Cookie
+		if err := rows.Scan(&c.Host, &c.Name, &c.Value, &c.Path, &c.Expires, &c.Secure, &c.HttpOnly); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// Delete 清除某个 host 下的全部 cookie，供 DELETE /cookies?host= 使用。
+func Delete(host string) (int64, error) {
+	db := storage.DB()
+	if db == nil {
+		return 0, fmt.Errorf("cookiejar: storage not initialized")
+	}
+	res, err := db.Exec(`DELETE FROM cookies WHERE host = ?`, host)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Import 直接 upsert 一组 Cookie（POST /cookies/import 的 JSON 数组分支）。
+func Import(cookies []Cookie) (int, error) {
+	db := storage.DB()
+	if db == nil {
+		return 0, fmt.Errorf("cookiejar: storage not initialized")
+	}
+	n := 0
+	for _, c := range cookies {
+		path := c.Path
+		if path == "" {
+			path = "/"
+		}
+		if _, err := db.Exec(`
+INSERT INTO cookies(host, name, value, path, expires, secure, http_only)
+VALUES (?,?,?,?,?,?,?)
+ON CONFLICT(host, name, path) DO UPDATE SET
+	value = excluded.value,
+	expires = excluded.expires,
+	secure = excluded.secure,
+	http_only = excluded.http_only`,
+			c.Host, c.Name, c.Value, path, c.Expires, c.Secure, c.HttpOnly,
+		); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,83 @@
+package cookiejar
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+// ImportFirefox 从一个 Firefox cookies.sqlite 文件（或其 profile 目录，自动挑选
+// *.default-release）只读打开，把 moz_cookies 中 host 包含 hostContains 的行复制进本地
+// cookies 表，返回导入的条数。hostContains 为空时导入全部。
+func ImportFirefox(path, hostContains string) (int, error) {
+	cookiesPath, err := resolveFirefoxCookiesPath(path)
+	if err != nil {
+		return 0, err
+	}
+
+	src, err := sql.Open("sqlite", "file:"+cookiesPath+"?mode=ro&immutable=1")
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	query := `SELECT host, name, value, path, expiry, isSecure, isHttpOnly FROM moz_cookies`
+	args := []any{}
+	if hostContains != "" {
+		query += ` WHERE host LIKE ?`
+		args = append(args, "%"+hostContains+"%")
+	}
+
+	rows, err := src.Query(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("cookiejar: read moz_cookies failed: %w", err)
+	}
+	defer rows.Close()
+
+	var cookies []Cookie
+	for rows.Next() {
+		var c Cookie
+		var secure, httpOnly int
+		if err := rows.Scan(&c.Host, &c.Name, &c.Value, &c.Path, &c.Expires, &secure, &httpOnly); err != nil {
+			return 0, err
+		}
+		c.Secure = secure != 0
+		c.HttpOnly = httpOnly != 0
+		cookies = append(cookies, c)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	return Import(cookies)
+}
+
+// resolveFirefoxCookiesPath 接受一个直接指向 cookies.sqlite 的路径，或一个 Firefox profile
+// 根目录——后者会自动挑选名字匹配 *.default-release 的 profile 子目录。
+func resolveFirefoxCookiesPath(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return path, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return "", err
+	}
+	for _, e := range entries {
+		if e.IsDir() && strings.HasSuffix(e.Name(), ".default-release") {
+			candidate := filepath.Join(path, e.Name(), "cookies.sqlite")
+			if _, err := os.Stat(candidate); err == nil {
+				return candidate, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("cookiejar: no *.default-release profile with cookies.sqlite found under %s", path)
+}
@@ -0,0 +1,50 @@
+package cookiejar
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseNetscape 解析 Netscape cookie 文件格式（curl -c / 浏览器插件常用的导出格式）：
+// 每行 7 个 TAB 分隔字段：domain, includeSubdomains, path, secure, expires, name, value。
+// 以 "#HttpOnly_" 开头的行是带 HttpOnly 标记的同格式数据，其余以 "#" 开头的行视为注释。
+func ParseNetscape(data []byte) ([]Cookie, error) {
+	var out []Cookie
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		httpOnly := false
+		if strings.HasPrefix(line, "#HttpOnly_") {
+			httpOnly = true
+			line = strings.TrimPrefix(line, "#HttpOnly_")
+		} else if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("malformed netscape cookie line: %q", line)
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed expires in line %q: %w", line, err)
+		}
+		out = append(out, Cookie{
+			Host:     fields[0],
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Expires:  expires,
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		})
+	}
+	return out, scanner.Err()
+}
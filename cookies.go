@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sqleo/parse-video/cookiejar"
+)
+
+// handleCookiesImport 实现 POST /cookies/import：请求体可以是 Netscape cookie 文件，
+// 也可以是一个 JSON cookie 数组，按 Content-Type 是否包含 json 来判断。
+func handleCookiesImport(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, HttpResponse{Code: 400, Msg: err.Error()})
+		return
+	}
+
+	var cookies []cookiejar.Cookie
+	if strings.Contains(c.ContentType(), "json") {
+		if err := json.Unmarshal(body, &cookies); err != nil {
+			c.JSON(http.StatusBadRequest, HttpResponse{Code: 400, Msg: err.Error()})
+			return
+		}
+	} else {
+		cookies, err = cookiejar.ParseNetscape(body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, HttpResponse{Code: 400, Msg: err.Error()})
+			return
+		}
+	}
+
+	n, err := cookiejar.Import(cookies)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, HttpResponse{Code: 500, Msg: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, HttpResponse{Code: 200, Msg: "ok", Data: gin.H{"imported": n}})
+}
+
+// handleCookiesImportFirefox 实现 POST /cookies/import/firefox：请求体给出一个文件系统路径
+// （直接指向 cookies.sqlite，或 Firefox profile 根目录），?host_contains= 过滤要导入的域名。
+func handleCookiesImportFirefox(c *gin.Context) {
+	var body struct {
+		Path string `json:"path"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.Path == "" {
+		c.JSON(http.StatusBadRequest, HttpResponse{Code: 400, Msg: "missing path"})
+		return
+	}
+
+	n, err := cookiejar.ImportFirefox(body.Path, c.Query("host_contains"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, HttpResponse{Code: 500, Msg: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, HttpResponse{Code: 200, Msg: "ok", Data: gin.H{"imported": n}})
+}
+
+// handleCookiesList 实现 GET /cookies?host=，用于排查某个域名当前持久化了哪些 cookie。
+func handleCookiesList(c *gin.Context) {
+	host := c.Query("host")
+	if host == "" {
+		c.JSON(http.StatusBadRequest, HttpResponse{Code: 400, Msg: "missing host"})
+		return
+	}
+	cookies, err := cookiejar.List(host)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, HttpResponse{Code: 500, Msg: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, HttpResponse{Code: 200, Msg: "ok", Data: cookies})
+}
+
+// handleCookiesDelete 实现 DELETE /cookies?host=，用于驱逐失效或误导入的 cookie。
+func handleCookiesDelete(c *gin.Context) {
+	host := c.Query("host")
+	if host == "" {
+		c.JSON(http.StatusBadRequest, HttpResponse{Code: 400, Msg: "missing host"})
+		return
+	}
+	n, err := cookiejar.Delete(host)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, HttpResponse{Code: 500, Msg: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, HttpResponse{Code: 200, Msg: "ok", Data: gin.H{"deleted": n}})
+}
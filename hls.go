@@ -0,0 +1,407 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/grafov/m3u8"
+)
+
+// isM3U8Response 判断响应是否为 HLS 播放列表，按 Content-Type 或 URL 后缀判定。
+func isM3U8Response(rawURL, contentType string) bool {
+	if strings.Contains(contentType, "mpegurl") {
+		return true
+	}
+	u, err := url.Parse(rawURL)
+	return err == nil && strings.HasSuffix(strings.ToLower(u.Path), ".m3u8")
+}
+
+// hlsKey 描述 EXT-X-KEY 声明的 AES-128 解密参数。
+type hlsKey struct {
+	method string
+	uri    string
+	iv     []byte
+}
+
+// hlsSegment 是一个待下载的 TS 分片，携带解密这个分片所需的 key（如果有）。
+type hlsSegment struct {
+	index int
+	uri   string
+	key   *hlsKey
+}
+
+const hlsFetchConcurrency = 6
+
+// resolveHLSURL 将播放列表内的相对 URI 解析为绝对地址。
+func resolveHLSURL(base *url.URL, ref string) (string, error) {
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(refURL).String(), nil
+}
+
+// parseHexIV 解析 EXT-X-KEY 的 IV=0x... 十六进制串（兼容省略的 0x 前缀）。
+func parseHexIV(s string) ([]byte, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	if len(s)%2 != 0 {
+		s = "0" + s
+	}
+	out := make([]byte, len(s)/2)
+	for i := range out {
+		b, err := strconv.ParseUint(s[i*2:i*2+2], 16, 8)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = byte(b)
+	}
+	return out, nil
+}
+
+// segmentIV 优先使用 EXT-X-KEY 的 IV=，否则由分片序号派生大端 uint128。
+func segmentIV(iv []byte, seq int) []byte {
+	if len(iv) == 16 {
+		return iv
+	}
+	out := make([]byte, 16)
+	binary.BigEndian.PutUint64(out[8:], uint64(seq))
+	return out
+}
+
+// decryptAES128CBC 对单个 TS 分片按 AES-128-CBC 解密并去除 PKCS7 填充。
+func decryptAES128CBC(data, key, iv []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("ciphertext is not a multiple of the block size")
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+	if n := len(out); n > 0 {
+		pad := int(out[n-1])
+		if pad > 0 && pad <= aes.BlockSize && pad <= n {
+			out = out[:n-pad]
+		}
+	}
+	return out, nil
+}
+
+// fetchHLSKey 拉取 AES-128 key，同一 URI 在一次下载内只请求一次。
+func fetchHLSKey(client *http.Client, keyURL string, cache map[string][]byte, mu *sync.Mutex) ([]byte, error) {
+	// 整个拉取过程持有锁，避免多个 worker 同时未命中缓存而重复请求同一个 key
+	mu.Lock()
+	defer mu.Unlock()
+	if k, ok := cache[keyURL]; ok {
+		return k, nil
+	}
+
+	u, err := url.Parse(keyURL)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("GET", keyURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	applyAntiHotlinkHeaders(req, u)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("fetch key failed: status %d", resp.StatusCode)
+	}
+	key, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	cache[keyURL] = key
+	return key, nil
+}
+
+// fetchHLSSegment 下载一个 TS 分片，并在声明了 AES-128 key 时就地解密。
+func fetchHLSSegment(client *http.Client, seg hlsSegment, keyCache map[string][]byte, keyMu *sync.Mutex) ([]byte, error) {
+	u, err := url.Parse(seg.uri)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("GET", seg.uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	applyAntiHotlinkHeaders(req, u)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("segment %d fetch failed: status %d", seg.index, resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if seg.key != nil && strings.EqualFold(seg.key.method, "AES-128") {
+		key, err := fetchHLSKey(client, seg.key.uri, keyCache, keyMu)
+		if err != nil {
+			return nil, err
+		}
+		data, err = decryptAES128CBC(data, key, segmentIV(seg.key.iv, seg.index))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// fetchHLSSegmentsInOrder 用一个小的并发池抓取分片，再按播放列表顺序写入 w（reorder buffer）。
+func fetchHLSSegmentsInOrder(w io.Writer, client *http.Client, segments []hlsSegment) error {
+	if len(segments) == 0 {
+		return nil
+	}
+	results := make([][]byte, len(segments))
+	errs := make([]error, len(segments))
+
+	workers := hlsFetchConcurrency
+	if len(segments) < workers {
+		workers = len(segments)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	keyCache := map[string][]byte{}
+	var keyMu sync.Mutex
+
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				data, err := fetchHLSSegment(client, segments[idx], keyCache, &keyMu)
+				results[idx] = data
+				errs[idx] = err
+			}
+		}()
+	}
+	go func() {
+		for i := range segments {
+			jobs <- i
+		}
+		close(jobs)
+	}()
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(results[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveHLSVariant 在 master playlist 中挑选目标 variant：优先 ?variant= 指定（下标或 URI），否则取最高码率。
+func resolveHLSVariant(master *m3u8.MasterPlaylist, variant string) (*m3u8.Variant, error) {
+	if len(master.Variants) == 0 {
+		return nil, fmt.Errorf("master playlist has no variants")
+	}
+	if variant != "" {
+		if idx, err := strconv.Atoi(variant); err == nil && idx >= 0 && idx < len(master.Variants) {
+			return master.Variants[idx], nil
+		}
+		for _, v := range master.Variants {
+			if v.URI == variant {
+				return v, nil
+			}
+		}
+	}
+	best := master.Variants[0]
+	for _, v := range master.Variants[1:] {
+		if v.Bandwidth > best.Bandwidth {
+			best = v
+		}
+	}
+	return best, nil
+}
+
+// buildHLSSegments 把媒体播放列表的分片解析为绝对地址 + 当前生效 Key 的待下载列表。
+func buildHLSSegments(base *url.URL, media *m3u8.MediaPlaylist) ([]hlsSegment, error) {
+	var out []hlsSegment
+	var curKey *hlsKey
+	seq := int(media.SeqNo)
+	for _, s := range media.Segments {
+		if s == nil {
+			continue
+		}
+		if s.Key != nil {
+			if s.Key.Method == "" || strings.EqualFold(s.Key.Method, "NONE") {
+				curKey = nil
+			} else {
+				var iv []byte
+				if s.Key.IV != "" {
+					iv, _ = parseHexIV(s.Key.IV)
+				}
+				keyURL, err := resolveHLSURL(base, s.Key.URI)
+				if err != nil {
+					return nil, err
+				}
+				curKey = &hlsKey{method: s.Key.Method, uri: keyURL, iv: iv}
+			}
+		}
+		segURL, err := resolveHLSURL(base, s.URI)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, hlsSegment{index: seq, uri: segURL, key: curKey})
+		seq++
+	}
+	return out, nil
+}
+
+// muxToMP4 若 PATH 中存在 ffmpeg，则把拼接后的 TS 流转封装为 MP4 再写入 w。
+func muxToMP4(w io.Writer, ts io.Reader) error {
+	ffmpegPath, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return fmt.Errorf("ffmpeg not found on PATH: %w", err)
+	}
+	cmd := exec.CommandContext(context.Background(), ffmpegPath,
+		"-loglevel", "error",
+		"-i", "pipe:0",
+		"-c", "copy",
+		"-movflags", "frag_keyframe+empty_moov",
+		"-f", "mp4",
+		"pipe:1",
+	)
+	cmd.Stdin = ts
+	cmd.Stdout = w
+	return cmd.Run()
+}
+
+// serveHLS 处理 /download 中判定为 HLS 播放列表的情形：解析 master/media playlist，
+// 按需拉取并解密分片，最终拼接为一个连续文件写回响应。master playlist 会先按
+// ?variant= 或最高码率挑选一个媒体播放列表再继续。
+func serveHLS(c *gin.Context, client *http.Client, playlistURL string, body io.Reader, filename string) {
+	base, err := url.Parse(playlistURL)
+	if err != nil {
+		c.String(http.StatusBadGateway, fmt.Sprintf("invalid playlist url: %v", err))
+		return
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		c.String(http.StatusBadGateway, fmt.Sprintf("read playlist failed: %v", err))
+		return
+	}
+
+	playlist, listType, err := m3u8.Decode(*bytes.NewBuffer(data), true)
+	if err != nil {
+		c.String(http.StatusBadGateway, fmt.Sprintf("parse m3u8 failed: %v", err))
+		return
+	}
+
+	var media *m3u8.MediaPlaylist
+	switch listType {
+	case m3u8.MASTER:
+		master := playlist.(*m3u8.MasterPlaylist)
+		variant, err := resolveHLSVariant(master, c.Query("variant"))
+		if err != nil {
+			c.String(http.StatusBadGateway, err.Error())
+			return
+		}
+		variantURL, err := resolveHLSURL(base, variant.URI)
+		if err != nil {
+			c.String(http.StatusBadGateway, err.Error())
+			return
+		}
+		vu, err := url.Parse(variantURL)
+		if err != nil {
+			c.String(http.StatusBadGateway, err.Error())
+			return
+		}
+		req, err := http.NewRequest("GET", variantURL, nil)
+		if err != nil {
+			c.String(http.StatusBadGateway, err.Error())
+			return
+		}
+		applyAntiHotlinkHeaders(req, vu)
+		resp, err := client.Do(req)
+		if err != nil {
+			c.String(http.StatusBadGateway, fmt.Sprintf("fetch variant failed: %v", err))
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+			c.String(http.StatusBadGateway, fmt.Sprintf("fetch variant failed: status %d", resp.StatusCode))
+			return
+		}
+		vdata, err := io.ReadAll(resp.Body)
+		if err != nil {
+			c.String(http.StatusBadGateway, err.Error())
+			return
+		}
+		mplaylist, mtype, err := m3u8.Decode(*bytes.NewBuffer(vdata), true)
+		if err != nil || mtype != m3u8.MEDIA {
+			c.String(http.StatusBadGateway, fmt.Sprintf("variant is not a media playlist: %v", err))
+			return
+		}
+		media = mplaylist.(*m3u8.MediaPlaylist)
+		base = vu
+	case m3u8.MEDIA:
+		media = playlist.(*m3u8.MediaPlaylist)
+	default:
+		c.String(http.StatusBadGateway, "unsupported m3u8 playlist type")
+		return
+	}
+
+	segments, err := buildHLSSegments(base, media)
+	if err != nil {
+		c.String(http.StatusBadGateway, fmt.Sprintf("resolve segments failed: %v", err))
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := fetchHLSSegmentsInOrder(&buf, client, segments); err != nil {
+		c.String(http.StatusBadGateway, fmt.Sprintf("fetch segments failed: %v", err))
+		return
+	}
+
+	if !strings.Contains(filename, ".") {
+		filename += ".ts"
+	}
+
+	if c.Query("mux") == "mp4" {
+		mp4Name := strings.TrimSuffix(filename, path.Ext(filename)) + ".mp4"
+		c.Header("Content-Type", "video/mp4")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", url.QueryEscape(mp4Name)))
+		c.Status(http.StatusOK)
+		if err := muxToMP4(c.Writer, &buf); err != nil {
+			log.Printf("mux to mp4 failed: %v", err)
+		}
+		return
+	}
+
+	c.Header("Content-Type", "video/mp2t")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", url.QueryEscape(filename)))
+	c.Status(http.StatusOK)
+	_, _ = io.Copy(c.Writer, &buf)
+}
@@ -3,7 +3,15 @@ package main
 import (
 	"context"
 	"embed"
+	"errors"
 	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sqleo/parse-video/cookiejar"
+	"github.com/sqleo/parse-video/maintenance"
+	"github.com/sqleo/parse-video/parser"
+	"github.com/sqleo/parse-video/queue"
+	"github.com/sqleo/parse-video/rewrite"
+	"github.com/sqleo/parse-video/storage"
 	"html/template"
 	"io"
 	"io/fs"
@@ -16,10 +24,6 @@ import (
 	"strconv"
 	"strings"
 	"time"
-	"errors"
-	"github.com/gin-gonic/gin"
-	"github.com/sqleo/parse-video/parser"
-	"github.com/sqleo/parse-video/storage"
 )
 
 type HttpResponse struct {
@@ -31,6 +35,59 @@ type HttpResponse struct {
 //go:embed templates/*
 var files embed.FS
 
+// extForContentType 依据 Content-Type 推断一个合理的文件扩展名，未知类型返回空字符串。
+func extForContentType(ct string) string {
+	switch {
+	case strings.HasPrefix(ct, "video/mp4"):
+		return ".mp4"
+	case strings.HasPrefix(ct, "audio/mpeg"):
+		return ".mp3"
+	case strings.HasPrefix(ct, "image/jpeg"):
+		return ".jpg"
+	case strings.HasPrefix(ct, "image/png"):
+		return ".png"
+	case strings.Contains(ct, "mpegurl"):
+		return ".m3u8"
+	case strings.Contains(ct, "mp2t"):
+		return ".ts"
+	}
+	return ""
+}
+
+// applyAntiHotlinkHeaders 统一以 Douyin 来源访问，规避防盗链；供 /download 及 HLS 分片/key 请求共用。
+func applyAntiHotlinkHeaders(req *http.Request, u *url.URL) {
+	req.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.6 Mobile/15E148 Safari/604.1")
+	req.Header.Set("Accept", "*/*")
+	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9")
+	req.Header.Set("Connection", "keep-alive")
+	req.Header.Set("Range", "bytes=0-")
+	req.Header.Set("Origin", "https://www.douyin.com")
+	req.Header.Set("Referer", "https://www.douyin.com/")
+	req.Header.Set("Sec-Fetch-Dest", "video")
+	req.Header.Set("Sec-Fetch-Mode", "no-cors")
+	req.Header.Set("Sec-Fetch-Site", "cross-site")
+	req.Host = u.Host
+}
+
+// newAntiHotlinkClient 返回一个保留 UA/Referer 的重定向跟随客户端；不单独设置 Jar，
+// 登录态 Cookie 由 cookiejar.Init 接管的 http.DefaultTransport 统一注入/回收。
+func newAntiHotlinkClient() *http.Client {
+	return &http.Client{CheckRedirect: func(req2 *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		// 复制首个请求头，避免重定向后 UA/Referer 丢失
+		if len(via) > 0 {
+			for k, vv := range via[0].Header {
+				for _, v := range vv {
+					req2.Header.Add(k, v)
+				}
+			}
+		}
+		return nil
+	}}
+}
+
 func main() {
 	r := gin.Default()
 
@@ -43,6 +100,26 @@ func main() {
 		log.Fatalf("init sqlite storage failed: %v", err)
 	}
 
+	// 启动批量解析队列的 worker 池，并把重启前未完成的批次项重新排队
+	if err := queue.Init(); err != nil {
+		log.Fatalf("init batch queue failed: %v", err)
+	}
+
+	// 初始化持久化 cookie jar
+	if err := cookiejar.Init(); err != nil {
+		log.Fatalf("init cookiejar failed: %v", err)
+	}
+
+	// 启动保留期清理 / 归档 / 每日统计的后台调度
+	if err := maintenance.Start(); err != nil {
+		log.Fatalf("init maintenance scheduler failed: %v", err)
+	}
+
+	// 加载 CDN 域名改写规则（未配置 PARSE_VIDEO_REWRITE_CONFIG 时不做任何改写）
+	if err := rewrite.Init(); err != nil {
+		log.Fatalf("init rewrite config failed: %v", err)
+	}
+
 	// 根据相关环境变量，确定是否需要使用basic auth中间件验证用户
 	if os.Getenv("PARSE_VIDEO_USERNAME") != "" && os.Getenv("PARSE_VIDEO_PASSWORD") != "" {
 		r.Use(gin.BasicAuth(gin.Accounts{
@@ -100,33 +177,9 @@ func main() {
 		}
 
 		req, _ := http.NewRequest("GET", raw, nil)
-		// 统一以 Douyin 来源访问，规避防盗链
-		req.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.6 Mobile/15E148 Safari/604.1")
-		req.Header.Set("Accept", "*/*")
-		req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9")
-		req.Header.Set("Connection", "keep-alive")
-		req.Header.Set("Range", "bytes=0-")
-		req.Header.Set("Origin", "https://www.douyin.com")
-		req.Header.Set("Referer", "https://www.douyin.com/")
-		req.Header.Set("Sec-Fetch-Dest", "video")
-		req.Header.Set("Sec-Fetch-Mode", "no-cors")
-		req.Header.Set("Sec-Fetch-Site", "cross-site")
-		req.Host = u.Host
-
-		client := &http.Client{CheckRedirect: func(req2 *http.Request, via []*http.Request) error {
-			if len(via) >= 10 {
-				return errors.New("stopped after 10 redirects")
-			}
-			// 复制首个请求头，避免重定向后 UA/Referer 丢失
-			if len(via) > 0 {
-				for k, vv := range via[0].Header {
-					for _, v := range vv {
-						req2.Header.Add(k, v)
-					}
-				}
-			}
-			return nil
-		}}
+		applyAntiHotlinkHeaders(req, u)
+
+		client := newAntiHotlinkClient()
 		resp, err := client.Do(req)
 		if err != nil {
 			c.String(http.StatusBadGateway, fmt.Sprintf("fetch failed: %v", err))
@@ -140,6 +193,12 @@ func main() {
 			return
 		}
 
+		// HLS 播放列表：解析分片、按需解密并拼接为单个连续文件返回
+		if isM3U8Response(raw, resp.Header.Get("Content-Type")) {
+			serveHLS(c, client, raw, resp.Body, filename)
+			return
+		}
+
 		ct := resp.Header.Get("Content-Type")
 		if ct != "" {
 			c.Header("Content-Type", ct)
@@ -150,22 +209,7 @@ func main() {
 
 		// 若无扩展名，依据 Content-Type 追加合理扩展名，便于本地播放器识别
 		if !strings.Contains(filename, ".") {
-			var ext string
-			switch {
-			case strings.HasPrefix(ct, "video/mp4"):
-				ext = ".mp4"
-			case strings.HasPrefix(ct, "audio/mpeg"):
-				ext = ".mp3"
-			case strings.HasPrefix(ct, "image/jpeg"):
-				ext = ".jpg"
-			case strings.HasPrefix(ct, "image/png"):
-				ext = ".png"
-			case strings.Contains(ct, "mpegurl"):
-				ext = ".m3u8"
-			case strings.Contains(ct, "mp2t"):
-				ext = ".ts"
-			}
-			if ext != "" {
+			if ext := extForContentType(ct); ext != "" {
 				filename += ext
 			}
 		}
@@ -178,9 +222,35 @@ func main() {
 		_, _ = io.Copy(c.Writer, resp.Body)
 	})
 
+	// 一次性打包解析出的视频、音乐、封面与图集为 zip
+	r.GET("/bundle", handleBundle)
+
+	// 异步批量解析：提交一批链接/video_id，凭 job_id 轮询进度与结果
+	r.POST("/batch", handleBatchSubmit)
+	r.GET("/batch/:job_id", handleBatchProgress)
+
+	// Cookie 管理（依赖上面的 basic auth 中间件鉴权）
+	r.POST("/cookies/import", handleCookiesImport)
+	r.POST("/cookies/import/firefox", handleCookiesImportFirefox)
+	r.GET("/cookies", handleCookiesList)
+	r.DELETE("/cookies", handleCookiesDelete)
+
+	// 解析量时间序列，以及按需触发维护任务（依赖上面的 basic auth 中间件鉴权）
+	r.GET("/stats", handleStats)
+	r.POST("/admin/maintenance/run", handleMaintenanceRun)
+
 	r.GET("/video/share/url/parse", func(c *gin.Context) {
 		paramUrl := c.Query("url")
 		parseRes, err := parser.ParseVideoShareUrlByRegexp(paramUrl)
+
+		// 按配置把源站域名替换为 CDN，?rewrite=off 可跳过
+		var original *parser.VideoParseInfo
+		if err == nil && c.Query("rewrite") != "off" {
+			if rw, changed := rewrite.Apply(parseRes); changed {
+				original, parseRes = parseRes, rw
+			}
+		}
+
 		jsonRes := HttpResponse{
 			Code: 200,
 			Msg:  "解析成功",
@@ -194,12 +264,18 @@ func main() {
 		}
 
 		_ = storage.Append(c.Request.Context(), storage.Record{
-			Endpoint: "/video/share/url/parse",
-			Input:    storage.Input{ShareURL: paramUrl},
-			ClientIP: c.ClientIP(),
+			Endpoint:  "/video/share/url/parse",
+			Input:     storage.Input{ShareURL: paramUrl},
+			ClientIP:  c.ClientIP(),
 			UserAgent: c.GetHeader("User-Agent"),
-			Result:   parseRes,
-			Error:    func() string { if err != nil { return err.Error() }; return "" }(),
+			Result:    parseRes,
+			Original:  original,
+			Error: func() string {
+				if err != nil {
+					return err.Error()
+				}
+				return ""
+			}(),
 		})
 
 		c.JSON(http.StatusOK, jsonRes)
@@ -210,6 +286,15 @@ func main() {
 		source := c.Query("source")
 
 		parseRes, err := parser.ParseVideoId(source, videoId)
+
+		// 按配置把源站域名替换为 CDN，?rewrite=off 可跳过
+		var original *parser.VideoParseInfo
+		if err == nil && c.Query("rewrite") != "off" {
+			if rw, changed := rewrite.Apply(parseRes); changed {
+				original, parseRes = parseRes, rw
+			}
+		}
+
 		jsonRes := HttpResponse{
 			Code: 200,
 			Msg:  "解析成功",
@@ -223,13 +308,19 @@ func main() {
 		}
 
 		_ = storage.Append(c.Request.Context(), storage.Record{
-			Endpoint: "/video/id/parse",
-			Source:   source,
-			Input:    storage.Input{VideoID: videoId},
-			ClientIP: c.ClientIP(),
+			Endpoint:  "/video/id/parse",
+			Source:    source,
+			Input:     storage.Input{VideoID: videoId},
+			ClientIP:  c.ClientIP(),
 			UserAgent: c.GetHeader("User-Agent"),
-			Result:   parseRes,
-			Error:    func() string { if err != nil { return err.Error() }; return "" }(),
+			Result:    parseRes,
+			Original:  original,
+			Error: func() string {
+				if err != nil {
+					return err.Error()
+				}
+				return ""
+			}(),
 		})
 
 		c.JSON(200, jsonRes)
@@ -0,0 +1,294 @@
+// Package maintenance 在后台按 cron 调度对 storage 的 SQLite 日志做保留期清理、
+// 导出归档与每日统计聚合，并提供一个可按需触发的入口供 /admin/maintenance/run 使用。
+package maintenance
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/sqleo/parse-video/storage"
+)
+
+const (
+	defaultRetentionDays = 90
+	defaultArchiveDir    = "data/archive"
+
+	defaultRetentionCron = "0 3 * * *"  // 每天 03:00
+	defaultVacuumCron    = "0 4 * * 0"  // 每周日 04:00
+	defaultStatsCron     = "30 2 * * *" // 每天 02:30，先于 retention 统计当天数据
+)
+
+// JobRetention、JobVacuum、JobStats 是 POST /admin/maintenance/run?job= 接受的任务名。
+const (
+	JobRetention = "retention"
+	JobVacuum    = "vacuum"
+	JobStats     = "stats"
+)
+
+var sched *cron.Cron
+
+// Start 创建 daily_stats 表并按环境变量里的 cron 表达式（或默认值）启动调度。
+func Start() error {
+	if err := initSchema(); err != nil {
+		return err
+	}
+
+	sched = cron.New()
+	jobs := []struct {
+		name string
+		expr string
+		fn   func(context.Context) error
+	}{
+		{JobStats, envOr("PARSE_VIDEO_STATS_CRON", defaultStatsCron), RunStats},
+		{JobRetention, envOr("PARSE_VIDEO_RETENTION_CRON", defaultRetentionCron), RunRetention},
+		{JobVacuum, envOr("PARSE_VIDEO_VACUUM_CRON", defaultVacuumCron), RunVacuum},
+	}
+	for _, j := range jobs {
+		name, fn := j.name, j.fn
+		if _, err := sched.AddFunc(j.expr, func() {
+			if err := fn(context.Background()); err != nil {
+				log.Printf("maintenance: scheduled job %q failed: %v", name, err)
+			}
+		}); err != nil {
+			return fmt.Errorf("maintenance: bad cron expression for %s (%q): %w", j.name, j.expr, err)
+		}
+	}
+	sched.Start()
+	return nil
+}
+
+// RunJob 按名字同步执行一个维护任务，供管理端点按需触发，不等待下一次调度时间。
+func RunJob(ctx context.Context, name string) error {
+	switch name {
+	case JobRetention:
+		return RunRetention(ctx)
+	case JobVacuum:
+		return RunVacuum(ctx)
+	case JobStats:
+		return RunStats(ctx)
+	default:
+		return fmt.Errorf("maintenance: unknown job %q", name)
+	}
+}
+
+func initSchema() error {
+	db := storage.DB()
+	if db == nil {
+		return fmt.Errorf("maintenance: storage not initialized")
+	}
+	_, err := db.Exec(`
+CREATE TABLE IF NOT EXISTS daily_stats (
+	date TEXT NOT NULL,
+	source TEXT NOT NULL,
+	count INTEGER NOT NULL,
+	error_count INTEGER NOT NULL,
+	top_client_ip TEXT,
+	PRIMARY KEY (date, source)
+);
+`)
+	return err
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func retentionDays() int {
+	if v := os.Getenv("PARSE_VIDEO_RETENTION_DAYS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return defaultRetentionDays
+}
+
+func archiveDir() string {
+	if v := os.Getenv("PARSE_VIDEO_ARCHIVE_DIR"); v != "" {
+		return v
+	}
+	return defaultArchiveDir
+}
+
+// RunVacuum 执行 SQLite 的 VACUUM，回收删除记录后留下的空间。
+func RunVacuum(ctx context.Context) error {
+	db := storage.DB()
+	if db == nil {
+		return fmt.Errorf("maintenance: storage not initialized")
+	}
+	_, err := db.ExecContext(ctx, `VACUUM`)
+	return err
+}
+
+// RunRetention 把早于保留期的记录完整导出为 NDJSON（gzip 压缩），再按导出时收集到的那批 id
+// 删除——而不是按 cutoff 重新筛选，避免导出与删除之间新写入的、恰好也早于 cutoff 的记录被
+// 直接删掉而没有进入归档。导出内容覆盖 records 表全部列（含解析结果、改写前原始结果与报错
+// 信息），保证归档是一份可还原的完整备份。
+func RunRetention(ctx context.Context) error {
+	db := storage.DB()
+	if db == nil {
+		return fmt.Errorf("maintenance: storage not initialized")
+	}
+	cutoff := time.Now().AddDate(0, 0, -retentionDays()).Format(time.RFC3339)
+
+	rows, err := db.QueryContext(ctx, `
+SELECT id, ts, endpoint, source, share_url, video_id, client_ip, user_agent,
+       title, video_url, music_url, cover_url, images_json,
+       author_uid, author_name, author_avatar, error, COALESCE(job_id, ''), COALESCE(original, '')
+FROM records WHERE ts < ?`, cutoff)
+	if err != nil {
+		return err
+	}
+	type row struct {
+		ID           int64  `json:"id"`
+		TS           string `json:"ts"`
+		Endpoint     string `json:"endpoint"`
+		Source       string `json:"source"`
+		ShareURL     string `json:"share_url"`
+		VID          string `json:"video_id"`
+		ClientIP     string `json:"client_ip"`
+		UserAgent    string `json:"user_agent"`
+		Title        string `json:"title"`
+		VideoURL     string `json:"video_url"`
+		MusicURL     string `json:"music_url"`
+		CoverURL     string `json:"cover_url"`
+		ImagesJSON   string `json:"images_json"`
+		AuthorUID    string `json:"author_uid"`
+		AuthorName   string `json:"author_name"`
+		AuthorAvatar string `json:"author_avatar"`
+		Error        string `json:"error"`
+		JobID        string `json:"job_id"`
+		Original     string `json:"original"`
+	}
+	var toDelete []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(
+			&r.ID, &r.TS, &r.Endpoint, &r.Source, &r.ShareURL, &r.VID, &r.ClientIP, &r.UserAgent,
+			&r.Title, &r.VideoURL, &r.MusicURL, &r.CoverURL, &r.ImagesJSON,
+			&r.AuthorUID, &r.AuthorName, &r.AuthorAvatar, &r.Error, &r.JobID, &r.Original,
+		); err != nil {
+			rows.Close()
+			return err
+		}
+		toDelete = append(toDelete, r)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	rows.Close()
+
+	if len(toDelete) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(archiveDir(), 0o755); err != nil {
+		return err
+	}
+	archivePath := filepath.Join(archiveDir(), time.Now().Format("2006-01-02")+".ndjson.gz")
+	f, err := os.OpenFile(archivePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(f)
+	enc := json.NewEncoder(gz)
+	for _, r := range toDelete {
+		if err := enc.Encode(r); err != nil {
+			gz.Close()
+			f.Close()
+			return err
+		}
+	}
+	if err := gz.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	ids := make([]int64, len(toDelete))
+	for i, r := range toDelete {
+		ids[i] = r.ID
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := deleteByIDs(ctx, tx, ids); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// retentionDeleteBatchSize 控制单条 DELETE ... WHERE id IN (...) 携带的 id 数量，避免超过
+// SQLite 默认的 999 个绑定参数上限。
+const retentionDeleteBatchSize = 500
+
+// deleteByIDs 分批按 id 删除 records 行，保证只删掉导出阶段实际落盘归档过的那些行。
+func deleteByIDs(ctx context.Context, tx *sql.Tx, ids []int64) error {
+	for len(ids) > 0 {
+		n := retentionDeleteBatchSize
+		if n > len(ids) {
+			n = len(ids)
+		}
+		batch := ids[:n]
+		ids = ids[n:]
+
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(batch)), ",")
+		args := make([]any, len(batch))
+		for i, id := range batch {
+			args[i] = id
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM records WHERE id IN (`+placeholders+`)`, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunStats 用一条 INSERT ... SELECT 按 date(ts)、source 重新计算当天及之前的 daily_stats，
+// 包含总量、报错数，以及当天同一 source 下请求最多的 client_ip。
+func RunStats(ctx context.Context) error {
+	db := storage.DB()
+	if db == nil {
+		return fmt.Errorf("maintenance: storage not initialized")
+	}
+	_, err := db.ExecContext(ctx, `
+INSERT INTO daily_stats(date, source, count, error_count, top_client_ip)
+SELECT
+	date(r1.ts) AS d,
+	COALESCE(r1.source, '') AS src,
+	COUNT(*) AS cnt,
+	SUM(CASE WHEN TRIM(r1.error) != '' THEN 1 ELSE 0 END) AS err_cnt,
+	(
+		SELECT r2.client_ip FROM records r2
+		WHERE date(r2.ts) = date(r1.ts) AND COALESCE(r2.source, '') = COALESCE(r1.source, '')
+		GROUP BY r2.client_ip
+		ORDER BY COUNT(*) DESC
+		LIMIT 1
+	) AS top_client_ip
+FROM records r1
+GROUP BY d, src
+ON CONFLICT(date, source) DO UPDATE SET
+	count = excluded.count,
+	error_count = excluded.error_count,
+	top_client_ip = excluded.top_client_ip
+`)
+	return err
+}
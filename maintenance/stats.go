@@ -0,0 +1,56 @@
+package maintenance
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sqleo/parse-video/storage"
+)
+
+// DailyStat 是 daily_stats 表的一行，供 GET /stats 渲染为时间序列图表。
+type DailyStat struct {
+	Date        string `json:"date"`
+	Source      string `json:"source"`
+	Count       int    `json:"count"`
+	ErrorCount  int    `json:"error_count"`
+	TopClientIP string `json:"top_client_ip,omitempty"`
+}
+
+// GetStats 按 [from, to]（闭区间，YYYY-MM-DD，留空不限制该端）查询 daily_stats，按日期升序返回。
+func GetStats(ctx context.Context, from, to string) ([]DailyStat, error) {
+	db := storage.DB()
+	if db == nil {
+		return nil, fmt.Errorf("maintenance: storage not initialized")
+	}
+
+	where := "1=1"
+	args := []any{}
+	if from != "" {
+		where += " AND date >= ?"
+		args = append(args, from)
+	}
+	if to != "" {
+		where += " AND date <= ?"
+		args = append(args, to)
+	}
+
+	rows, err := db.QueryContext(ctx, `
+SELECT date, source, count, error_count, COALESCE(top_client_ip, '')
+FROM daily_stats
+WHERE `+where+`
+ORDER BY date ASC, source ASC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []DailyStat
+	for rows.Next() {
+		var s DailyStat
+		if err := rows.Scan(&s.Date, &s.Source, &s.Count, &s.ErrorCount, &s.TopClientIP); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
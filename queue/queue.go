@@ -0,0 +1,339 @@
+// Package queue 实现一个持久化的异步批量解析队列：POST /batch 提交一批待解析项，
+// 一个可配置大小的 worker 池从队列中取出并调用 parser 完成解析，结果落到 storage 的
+// records 表中。任务与待处理项本身也持久化在 SQLite 里，进程重启后未完成的项会被重新入队。
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sqleo/parse-video/parser"
+	"github.com/sqleo/parse-video/storage"
+)
+
+// Item 是一次批量解析请求中的一项：要么是分享链接，要么是 source+video_id 组合。
+type Item struct {
+	ShareURL string `json:"share_url,omitempty"`
+	Source   string `json:"source,omitempty"`
+	VideoID  string `json:"video_id,omitempty"`
+}
+
+// Progress 描述一个批次任务的整体进度。
+type Progress struct {
+	JobID   string           `json:"job_id"`
+	Total   int              `json:"total"`
+	Pending int              `json:"pending"`
+	Done    int              `json:"done"`
+	Failed  int              `json:"failed"`
+	Status  string           `json:"status"` // pending | done
+	Results []storage.Record `json:"results,omitempty"`
+}
+
+const (
+	itemStatusPending = "pending"
+	itemStatusDone    = "done"
+	itemStatusFailed  = "failed"
+
+	jobStatusPending = "pending"
+	jobStatusDone    = "done"
+)
+
+const defaultWorkers = 4
+
+var (
+	workCh chan int64
+	wg     sync.WaitGroup
+)
+
+// Init 建表、按 PARSE_VIDEO_WORKERS 启动 worker 池，并把上次未完成的批次项重新入队。
+func Init() error {
+	db := storage.DB()
+	if db == nil {
+		return fmt.Errorf("queue: storage not initialized")
+	}
+	if _, err := db.Exec(`
+CREATE TABLE IF NOT EXISTS batch_jobs (
+	job_id TEXT PRIMARY KEY,
+	created_at TEXT NOT NULL,
+	total INTEGER NOT NULL,
+	status TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS batch_items (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	job_id TEXT NOT NULL,
+	share_url TEXT,
+	source TEXT,
+	video_id TEXT,
+	status TEXT NOT NULL,
+	error TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_batch_items_job_id ON batch_items(job_id);
+`); err != nil {
+		return err
+	}
+
+	workers := defaultWorkers
+	if v := os.Getenv("PARSE_VIDEO_WORKERS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			workers = n
+		}
+	}
+
+	workCh = make(chan int64, 1024)
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go worker()
+	}
+
+	return requeuePending()
+}
+
+// requeuePending 把「所属批次尚未标记为 done」的待处理项重新送入 worker 队列，
+// 覆盖进程在批次跑到一半时重启的情形。
+func requeuePending() error {
+	rows, err := storage.DB().Query(`
+SELECT bi.id FROM batch_items bi
+JOIN batch_jobs bj ON bj.job_id = bi.job_id
+WHERE bi.status = ? AND bj.status != ?`, itemStatusPending, jobStatusDone)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		log.Printf("queue: requeueing %d pending batch item(s) from a previous run", len(ids))
+	}
+	for _, id := range ids {
+		workCh <- id
+	}
+	return nil
+}
+
+// newJobID 生成一个不可预测的任务 id，格式为 job_ 加 16 字节随机十六进制。
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "job_" + hex.EncodeToString(b), nil
+}
+
+// Submit 把一批解析项写入 batch_jobs/batch_items 并送入 worker 队列，返回 job_id。
+func Submit(ctx context.Context, items []Item) (string, error) {
+	if len(items) == 0 {
+		return "", fmt.Errorf("queue: empty batch")
+	}
+	db := storage.DB()
+	if db == nil {
+		return "", fmt.Errorf("queue: storage not initialized")
+	}
+
+	jobID, err := newJobID()
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO batch_jobs(job_id, created_at, total, status) VALUES (?,?,?,?)`,
+		jobID, time.Now().Format(time.RFC3339), len(items), jobStatusPending,
+	); err != nil {
+		return "", err
+	}
+
+	ids := make([]int64, 0, len(items))
+	for _, it := range items {
+		res, err := tx.ExecContext(ctx,
+			`INSERT INTO batch_items(job_id, share_url, source, video_id, status) VALUES (?,?,?,?,?)`,
+			jobID, it.ShareURL, it.Source, it.VideoID, itemStatusPending,
+		)
+		if err != nil {
+			return "", err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return "", err
+		}
+		ids = append(ids, id)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+
+	for _, id := range ids {
+		workCh <- id
+	}
+	return jobID, nil
+}
+
+// GetProgress 返回一个批次的进度，任务完成后一并返回完整的解析结果列表。
+func GetProgress(ctx context.Context, jobID string) (*Progress, error) {
+	db := storage.DB()
+	if db == nil {
+		return nil, fmt.Errorf("queue: storage not initialized")
+	}
+
+	var total int
+	if err := db.QueryRowContext(ctx, `SELECT total FROM batch_jobs WHERE job_id = ?`, jobID).Scan(&total); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	p := &Progress{JobID: jobID, Total: total}
+	rows, err := db.QueryContext(ctx, `SELECT status, COUNT(*) FROM batch_items WHERE job_id = ? GROUP BY status`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		switch status {
+		case itemStatusPending:
+			p.Pending = n
+		case itemStatusDone:
+			p.Done = n
+		case itemStatusFailed:
+			p.Failed = n
+		}
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, err
+	}
+	rows.Close()
+
+	if p.Pending == 0 {
+		p.Status = jobStatusDone
+		results, err := queryAllResults(ctx, jobID, total)
+		if err != nil {
+			return nil, err
+		}
+		p.Results = results
+	} else {
+		p.Status = jobStatusPending
+	}
+	return p, nil
+}
+
+// queryAllResults 分页取出某个 job 的全部结果——storage.Query 出于保护线上接口的目的把
+// Limit 收窄到 200，单次查询拿不全大批次（例如 500 条）的结果，这里按页循环直到取满。
+const queryPageSize = 200
+
+func queryAllResults(ctx context.Context, jobID string, total int) ([]storage.Record, error) {
+	out := make([]storage.Record, 0, total)
+	for offset := 0; ; offset += queryPageSize {
+		page, err := storage.Query(ctx, storage.QueryOptions{JobID: jobID, Endpoint: "/batch", Limit: queryPageSize, Offset: offset})
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, page...)
+		if len(page) < queryPageSize {
+			return out, nil
+		}
+	}
+}
+
+// markJobDoneIfComplete 在某个批次项处理完成后检查同批次是否已全部处理完毕，若是则把
+// batch_jobs.status 置为 done，供 requeuePending 判断哪些批次在重启后还需要补跑。
+func markJobDoneIfComplete(db *sql.DB, jobID string) {
+	var pending int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM batch_items WHERE job_id = ? AND status = ?`, jobID, itemStatusPending).Scan(&pending); err != nil {
+		log.Printf("queue: check job %s completion failed: %v", jobID, err)
+		return
+	}
+	if pending > 0 {
+		return
+	}
+	if _, err := db.Exec(`UPDATE batch_jobs SET status = ? WHERE job_id = ?`, jobStatusDone, jobID); err != nil {
+		log.Printf("queue: mark job %s done failed: %v", jobID, err)
+	}
+}
+
+// worker 不断从队列取出待解析项：限速、解析、落库、更新 batch_items/batch_jobs 状态。
+func worker() {
+	defer wg.Done()
+	for id := range workCh {
+		processItem(id)
+	}
+}
+
+// processItem 解析单个批次项并记录结果，全程容错——单项失败不影响批次里的其他项。
+func processItem(id int64) {
+	db := storage.DB()
+
+	var jobID, shareURL, source, videoID string
+	err := db.QueryRow(`SELECT job_id, share_url, source, video_id FROM batch_items WHERE id = ?`, id).
+		Scan(&jobID, &shareURL, &source, &videoID)
+	if err != nil {
+		log.Printf("queue: load batch item %d failed: %v", id, err)
+		return
+	}
+
+	limiterFor(source).wait()
+
+	var (
+		parseRes *parser.VideoParseInfo
+		parseErr error
+	)
+	if shareURL != "" {
+		parseRes, parseErr = parser.ParseVideoShareUrlByRegexp(shareURL)
+	} else {
+		parseRes, parseErr = parser.ParseVideoId(source, videoID)
+	}
+
+	status := itemStatusDone
+	errMsg := ""
+	if parseErr != nil {
+		status = itemStatusFailed
+		errMsg = parseErr.Error()
+	}
+
+	if _, err := db.Exec(`UPDATE batch_items SET status = ?, error = ? WHERE id = ?`, status, errMsg, id); err != nil {
+		log.Printf("queue: update batch item %d failed: %v", id, err)
+	} else {
+		markJobDoneIfComplete(db, jobID)
+	}
+
+	ctx := context.Background()
+	if err := storage.Append(ctx, storage.Record{
+		Endpoint: "/batch",
+		Source:   source,
+		Input:    storage.Input{ShareURL: shareURL, VideoID: videoID},
+		Result:   parseRes,
+		Error:    errMsg,
+		JobID:    jobID,
+	}); err != nil {
+		log.Printf("queue: append record for batch item %d failed: %v", id, err)
+	}
+}
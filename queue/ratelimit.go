@@ -0,0 +1,64 @@
+package queue
+
+import (
+	"sync"
+	"time"
+)
+
+// 每个 source 默认每秒放行 2 个请求、允许 4 个的突发，避免单个大批次把某个平台的出口 IP 打封。
+// 这与长时间运行的采集器一贯的做法一致：按来源隔离限速，而不是对整个批次做单一节流。
+const (
+	defaultRateTokensPerSec = 2.0
+	defaultRateBurst        = 4.0
+)
+
+// tokenBucket 是一个简单的令牌桶限速器。
+type tokenBucket struct {
+	mu       sync.Mutex
+	tokens   float64
+	capacity float64
+	rate     float64
+	last     time.Time
+}
+
+func newTokenBucket(rate, capacity float64) *tokenBucket {
+	return &tokenBucket{tokens: capacity, capacity: capacity, rate: rate, last: time.Now()}
+}
+
+// wait 阻塞直到取到一个令牌。
+func (b *tokenBucket) wait() {
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return
+		}
+		sleep := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+		time.Sleep(sleep)
+	}
+}
+
+var limiters = struct {
+	mu sync.Mutex
+	m  map[string]*tokenBucket
+}{m: map[string]*tokenBucket{}}
+
+// limiterFor 返回给定 source 的令牌桶，首次访问时按默认速率创建。
+func limiterFor(source string) *tokenBucket {
+	limiters.mu.Lock()
+	defer limiters.mu.Unlock()
+	if b, ok := limiters.m[source]; ok {
+		return b
+	}
+	b := newTokenBucket(defaultRateTokensPerSec, defaultRateBurst)
+	limiters.m[source] = b
+	return b
+}
@@ -0,0 +1,136 @@
+// Package rewrite 按配置把 parser 返回的媒体 URL 的源站域名替换为 CDN CNAME，
+// 在 /video/share/url/parse 与 /video/id/parse 把结果返回给客户端之前生效。
+package rewrite
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sqleo/parse-video/parser"
+)
+
+// Rule 描述一条域名替换规则：host 命中 MatchHostRegex 时，替换为 ReplaceHost，
+// 按需强制 scheme、剔除指定的 query 参数（例如签名类、会过期的参数）。
+type Rule struct {
+	MatchHostRegex string   `yaml:"match_host_regex"`
+	ReplaceHost    string   `yaml:"replace_host"`
+	AlsoStripQuery []string `yaml:"also_strip_query"`
+	ForceScheme    string   `yaml:"force_scheme"`
+
+	matchHost *regexp.Regexp
+}
+
+var (
+	mu    sync.RWMutex
+	rules []*Rule
+)
+
+// Init 从 PARSE_VIDEO_REWRITE_CONFIG 指向的 YAML 文件加载规则；未设置该环境变量时，
+// Apply 直接原样返回，不做任何改写。
+func Init() error {
+	path := os.Getenv("PARSE_VIDEO_REWRITE_CONFIG")
+	if path == "" {
+		return nil
+	}
+	return Load(path)
+}
+
+// Load 读取并编译一份规则文件，原子地替换当前生效的规则集。
+func Load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var loaded []*Rule
+	if err := yaml.Unmarshal(data, &loaded); err != nil {
+		return fmt.Errorf("rewrite: parse config %s: %w", path, err)
+	}
+	for _, r := range loaded {
+		re, err := regexp.Compile(r.MatchHostRegex)
+		if err != nil {
+			return fmt.Errorf("rewrite: bad match_host_regex %q: %w", r.MatchHostRegex, err)
+		}
+		r.matchHost = re
+	}
+	mu.Lock()
+	rules = loaded
+	mu.Unlock()
+	return nil
+}
+
+// rewriteURL 用第一条命中的规则改写 raw；没有规则命中时原样返回，changed 为 false。
+func rewriteURL(raw string) (string, bool) {
+	if raw == "" {
+		return raw, false
+	}
+	mu.RLock()
+	current := rules
+	mu.RUnlock()
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw, false
+	}
+	for _, r := range current {
+		if !r.matchHost.MatchString(u.Hostname()) {
+			continue
+		}
+		u.Host = r.ReplaceHost
+		if r.ForceScheme != "" {
+			u.Scheme = r.ForceScheme
+		}
+		if len(r.AlsoStripQuery) > 0 {
+			q := u.Query()
+			for _, k := range r.AlsoStripQuery {
+				q.Del(k)
+			}
+			u.RawQuery = q.Encode()
+		}
+		return u.String(), true
+	}
+	return raw, false
+}
+
+// Apply 对一份 VideoParseInfo 做改写，返回一个改写后的副本以及是否发生了任何改写；
+// 不修改传入的 info，未命中任何规则时 changed 为 false。
+func Apply(info *parser.VideoParseInfo) (*parser.VideoParseInfo, bool) {
+	if info == nil {
+		return info, false
+	}
+	out := *info
+	changed := false
+
+	if v, ok := rewriteURL(info.VideoUrl); ok {
+		out.VideoUrl = v
+		changed = true
+	}
+	if v, ok := rewriteURL(info.MusicUrl); ok {
+		out.MusicUrl = v
+		changed = true
+	}
+	if v, ok := rewriteURL(info.CoverUrl); ok {
+		out.CoverUrl = v
+		changed = true
+	}
+	if len(info.Images) > 0 {
+		images := make([]string, len(info.Images))
+		copy(images, info.Images)
+		for i, img := range images {
+			if v, ok := rewriteURL(img); ok {
+				images[i] = v
+				changed = true
+			}
+		}
+		out.Images = images
+	}
+
+	if !changed {
+		return info, false
+	}
+	return &out, true
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sqleo/parse-video/maintenance"
+)
+
+// handleStats 实现 GET /stats?from=&to=，返回 daily_stats 的时间序列，供仪表盘画图使用。
+func handleStats(c *gin.Context) {
+	stats, err := maintenance.GetStats(c.Request.Context(), c.Query("from"), c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, HttpResponse{Code: 500, Msg: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, HttpResponse{Code: 200, Msg: "ok", Data: stats})
+}
+
+// handleMaintenanceRun 实现 POST /admin/maintenance/run?job=，立即同步触发一个维护任务，
+// 不必等待它下一次的 cron 调度时间，方便运维排查问题。
+func handleMaintenanceRun(c *gin.Context) {
+	job := c.Query("job")
+	if job == "" {
+		c.JSON(http.StatusBadRequest, HttpResponse{Code: 400, Msg: "missing job"})
+		return
+	}
+	if err := maintenance.RunJob(c.Request.Context(), job); err != nil {
+		c.JSON(http.StatusInternalServerError, HttpResponse{Code: 500, Msg: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, HttpResponse{Code: 200, Msg: "ok"})
+}
@@ -29,6 +29,11 @@ type Record struct {
 	UserAgent string                 `json:"user_agent,omitempty"`
 	Result    *parser.VideoParseInfo `json:"result,omitempty"`
 	Error     string                 `json:"error,omitempty"`
+	JobID     string                 `json:"job_id,omitempty"`
+	// Original 是 rewrite 包改写 CDN 域名之前的原始解析结果；为 nil 表示本次没有发生改写
+	// （包括客户端传了 ?rewrite=off 的情形）。Result 里保存的始终是最终返回给客户端的那份
+	// 改写后结果，两者对照即可审计客户端实际收到的 URL 与解析器产出的 URL。
+	Original *parser.VideoParseInfo `json:"original,omitempty"`
 }
 
 type QueryOptions struct {
@@ -38,6 +43,7 @@ type QueryOptions struct {
 	Endpoint string
 	Contains string // 模糊匹配 share_url 或 video_id
 	ClientIP string
+	JobID    string
 	Limit    int
 	Offset   int
 }
@@ -81,9 +87,55 @@ CREATE INDEX IF NOT EXISTS idx_records_source ON records(source);
 CREATE INDEX IF NOT EXISTS idx_records_endpoint ON records(endpoint);
 CREATE INDEX IF NOT EXISTS idx_records_client_ip ON records(client_ip);
 `)
+	if err != nil {
+		return err
+	}
+	if err := addColumnIfMissing("records", "job_id", "TEXT"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing("records", "original", "TEXT"); err != nil {
+		return err
+	}
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_records_job_id ON records(job_id);`)
 	return err
 }
 
+// addColumnIfMissing 为已经存在的表补一列，兼容从旧版本数据库升级而来、缺少新列的场景。
+func addColumnIfMissing(table, column, ddlType string) error {
+	rows, err := db.Query(`PRAGMA table_info(` + table + `)`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			ctype     string
+			notNull   int
+			dfltValue any
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &ctype, &notNull, &dfltValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return rows.Err()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	_, err = db.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + ddlType)
+	return err
+}
+
+// DB 暴露底层连接，供 queue、cookiejar 等同样落在这个 SQLite 文件里的子系统复用。
+func DB() *sql.DB {
+	return db
+}
+
 func Append(ctx context.Context, rec Record) error {
 	if db == nil {
 		return sql.ErrConnDone
@@ -103,15 +155,20 @@ func Append(ctx context.Context, rec Record) error {
 		aname = rec.Result.Author.Name
 		aavatar = rec.Result.Author.Avatar
 	}
+	var originalJSON string
+	if rec.Original != nil {
+		b, _ := json.Marshal(rec.Original)
+		originalJSON = string(b)
+	}
 	_, err := db.ExecContext(ctx, `
 INSERT INTO records(
 	ts, endpoint, source, share_url, video_id,
 	client_ip, user_agent, title, video_url, music_url, cover_url,
-	images_json, author_uid, author_name, author_avatar, error
-) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+	images_json, author_uid, author_name, author_avatar, error, job_id, original
+) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
 		ts, rec.Endpoint, rec.Source, rec.Input.ShareURL, rec.Input.VideoID,
 		rec.ClientIP, rec.UserAgent, title, videoURL, musicURL, coverURL,
-		imagesJSON, auid, aname, aavatar, strings.TrimSpace(rec.Error),
+		imagesJSON, auid, aname, aavatar, strings.TrimSpace(rec.Error), rec.JobID, originalJSON,
 	)
 	return err
 }
@@ -148,6 +205,10 @@ func Query(ctx context.Context, q QueryOptions) ([]Record, error) {
 		p := "%" + q.Contains + "%"
 		args = append(args, p, p)
 	}
+	if q.JobID != "" {
+		where = append(where, "job_id = ?")
+		args = append(args, q.JobID)
+	}
 	limit := q.Limit
 	if limit <= 0 || limit > 200 {
 		limit = 50
@@ -160,7 +221,7 @@ func Query(ctx context.Context, q QueryOptions) ([]Record, error) {
 	rows, err := db.QueryContext(ctx, `
 SELECT id, ts, endpoint, source, share_url, video_id, client_ip, user_agent,
        title, video_url, music_url, cover_url, images_json,
-       author_uid, author_name, author_avatar, error
+       author_uid, author_name, author_avatar, error, COALESCE(job_id, ''), COALESCE(original, '')
 FROM records
 WHERE `+strings.Join(where, " AND ")+`
 ORDER BY id DESC
@@ -172,35 +233,74 @@ LIMIT ? OFFSET ?`, append(args, limit, offset)...)
 
 	var out []Record
 	for rows.Next() {
-		var (
-			r                 Record
-			shareURL, videoID string
-			title, videoURL2, musicURL, coverURL, imagesJSON, auid, aname, aavatar, e string
-		)
-		if err := rows.Scan(
-			&r.ID, &r.Timestamp, &r.Endpoint, &r.Source, &shareURL, &videoID, &r.ClientIP, &r.UserAgent,
-			&title, &videoURL2, &musicURL, &coverURL, &imagesJSON,
-			&auid, &aname, &aavatar, &e,
-		); err != nil {
+		r, err := scanRecord(rows)
+		if err != nil {
 			return nil, err
 		}
-		r.Input = Input{ShareURL: shareURL, VideoID: videoID}
-		if title != "" || videoURL2 != "" || coverURL != "" || imagesJSON != "" || auid != "" || aname != "" || aavatar != "" {
-			r.Result = &parser.VideoParseInfo{
-				Title:    title,
-				VideoUrl: videoURL2,
-				MusicUrl: musicURL,
-				CoverUrl: coverURL,
-			}
-			_ = json.Unmarshal([]byte(imagesJSON), &r.Result.Images)
-			r.Result.Author.Uid = auid
-			r.Result.Author.Name = aname
-			r.Result.Author.Avatar = aavatar
-		}
-		r.Error = e
 		out = append(out, r)
 	}
 	return out, rows.Err()
 }
 
+// recordScanner 是 sql.Row 和 sql.Rows 共有的 Scan 方法，便于 scanRecord 同时服务单行与多行查询。
+type recordScanner interface {
+	Scan(dest ...any) error
+}
 
+// scanRecord 把一行 records 表记录解析为 Record，兼容 Query 与 GetByID 的列顺序。
+func scanRecord(row recordScanner) (Record, error) {
+	var (
+		r                                                                                       Record
+		shareURL, videoID                                                                       string
+		title, videoURL2, musicURL, coverURL, imagesJSON, auid, aname, aavatar, e, originalJSON string
+	)
+	if err := row.Scan(
+		&r.ID, &r.Timestamp, &r.Endpoint, &r.Source, &shareURL, &videoID, &r.ClientIP, &r.UserAgent,
+		&title, &videoURL2, &musicURL, &coverURL, &imagesJSON,
+		&auid, &aname, &aavatar, &e, &r.JobID, &originalJSON,
+	); err != nil {
+		return Record{}, err
+	}
+	if originalJSON != "" {
+		var orig parser.VideoParseInfo
+		if err := json.Unmarshal([]byte(originalJSON), &orig); err == nil {
+			r.Original = &orig
+		}
+	}
+	r.Input = Input{ShareURL: shareURL, VideoID: videoID}
+	if title != "" || videoURL2 != "" || coverURL != "" || imagesJSON != "" || auid != "" || aname != "" || aavatar != "" {
+		r.Result = &parser.VideoParseInfo{
+			Title:    title,
+			VideoUrl: videoURL2,
+			MusicUrl: musicURL,
+			CoverUrl: coverURL,
+		}
+		_ = json.Unmarshal([]byte(imagesJSON), &r.Result.Images)
+		r.Result.Author.Uid = auid
+		r.Result.Author.Name = aname
+		r.Result.Author.Avatar = aavatar
+	}
+	r.Error = e
+	return r, nil
+}
+
+// GetByID 按主键查询单条记录，供 /bundle 等需要复用既有解析结果的接口使用。
+func GetByID(ctx context.Context, id int64) (*Record, error) {
+	if db == nil {
+		return nil, sql.ErrConnDone
+	}
+	row := db.QueryRowContext(ctx, `
+SELECT id, ts, endpoint, source, share_url, video_id, client_ip, user_agent,
+       title, video_url, music_url, cover_url, images_json,
+       author_uid, author_name, author_avatar, error, COALESCE(job_id, ''), COALESCE(original, '')
+FROM records
+WHERE id = ?`, id)
+	r, err := scanRecord(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &r, nil
+}